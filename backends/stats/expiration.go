@@ -0,0 +1,144 @@
+package stats
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+func (m *memoryBackend) Expire(name string, seconds int) (int, error) {
+	return m.PExpire(name, seconds*1000)
+}
+
+func (m *memoryBackend) PExpire(name string, ms int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.expireIfNeededLocked(name) {
+		return 0, nil
+	}
+	_, hasValue := m.values[name]
+	_, hasCounter := m.counters[name]
+	if !hasValue && !hasCounter {
+		return 0, nil
+	}
+
+	deadline := time.Now().Add(time.Duration(ms) * time.Millisecond)
+	m.expires[name] = deadline
+	m.bumpVersionLocked(name)
+	// Recorded as an absolute deadline (PEXPIREAT), not the relative ms
+	// that was passed in, so replaying the AOF later doesn't re-apply
+	// the same TTL starting from whenever restart happens to occur.
+	m.append("PEXPIREAT", name, strconv.FormatInt(deadline.UnixMilli(), 10))
+	return 1, nil
+}
+
+// TTL returns the remaining time to live for name in seconds, -1 if it
+// has no expiration set and -2 if it doesn't exist, matching redis.
+func (m *memoryBackend) TTL(name string) (int, error) {
+	ms, err := m.PTTL(name)
+	if ms < 0 {
+		return ms, err
+	}
+	return ms / 1000, err
+}
+
+// PTTL is TTL with millisecond resolution.
+func (m *memoryBackend) PTTL(name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.expireIfNeededLocked(name) {
+		return -2, nil
+	}
+	_, hasValue := m.values[name]
+	_, hasCounter := m.counters[name]
+	if !hasValue && !hasCounter {
+		return -2, nil
+	}
+
+	exp, ok := m.expires[name]
+	if !ok {
+		return -1, nil
+	}
+	remaining := exp.Sub(time.Now())
+	if remaining <= 0 {
+		m.expireIfNeededLocked(name)
+		return -2, nil
+	}
+	return int(remaining / time.Millisecond), nil
+}
+
+// Persist removes any expiration set on name, turning it back into a
+// persistent key the way redis's PERSIST does.
+func (m *memoryBackend) Persist(name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.expireIfNeededLocked(name) {
+		return 0, nil
+	}
+	if _, ok := m.expires[name]; !ok {
+		return 0, nil
+	}
+	delete(m.expires, name)
+	m.bumpVersionLocked(name)
+	m.append("PERSIST", name)
+	return 1, nil
+}
+
+// activeExpireCycle implements the redis-style active expiration
+// algorithm: repeatedly sample a handful of keys that carry a TTL,
+// evict the ones that have expired, and keep going as long as a large
+// share of the sample was already expired (meaning there's likely more
+// to reclaim), subject to a bounded number of rounds so a burst of
+// expirations can't monopolize the sweeper goroutine forever.
+func (m *memoryBackend) activeExpireCycle() {
+	const sampleSize = 20
+	const maxRounds = 10
+	const expiredRatioThreshold = 0.25
+
+	for round := 0; round < maxRounds; round++ {
+		m.mu.Lock()
+		if len(m.expires) == 0 {
+			m.mu.Unlock()
+			return
+		}
+
+		keys := make([]string, 0, len(m.expires))
+		for k := range m.expires {
+			keys = append(keys, k)
+		}
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		if len(keys) > sampleSize {
+			keys = keys[:sampleSize]
+		}
+
+		expired := 0
+		for _, k := range keys {
+			if m.expireIfNeededLocked(k) {
+				expired++
+			}
+		}
+		m.mu.Unlock()
+
+		if len(keys) == 0 || float64(expired)/float64(len(keys)) <= expiredRatioThreshold {
+			return
+		}
+	}
+}
+
+// runExpireSweeper samples for and evicts expired keys every interval
+// until quit is closed.
+func (m *memoryBackend) runExpireSweeper(interval time.Duration, quit chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			m.activeExpireCycle()
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestMemoryBackend(t *testing.T) *memoryBackend {
+	t.Helper()
+	m, err := NewMemoryBackend()
+	if err != nil {
+		t.Fatalf("NewMemoryBackend: %v", err)
+	}
+	return m.(*memoryBackend)
+}
+
+func TestTTLNoExpirationAndMissingKey(t *testing.T) {
+	m := newTestMemoryBackend(t)
+
+	if ttl, _ := m.TTL("missing"); ttl != -2 {
+		t.Errorf("TTL(missing) = %d, want -2", ttl)
+	}
+
+	m.Set("persistent", "v")
+	if ttl, _ := m.TTL("persistent"); ttl != -1 {
+		t.Errorf("TTL(persistent) = %d, want -1", ttl)
+	}
+}
+
+func TestPExpireAndTTL(t *testing.T) {
+	m := newTestMemoryBackend(t)
+	m.Set("foo", "bar")
+
+	ok, err := m.PExpire("foo", 50)
+	if err != nil || ok != 1 {
+		t.Fatalf("PExpire(foo, 50) = (%d, %v), want (1, nil)", ok, err)
+	}
+
+	if ms, _ := m.PTTL("foo"); ms <= 0 || ms > 50 {
+		t.Errorf("PTTL(foo) = %d, want in (0, 50]", ms)
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if ms, _ := m.PTTL("foo"); ms != -2 {
+		t.Errorf("PTTL(foo) after expiring = %d, want -2", ms)
+	}
+	if v, _ := m.Get("foo"); v != "" {
+		t.Errorf("Get(foo) after expiring = %q, want empty", v)
+	}
+}
+
+func TestPersistRemovesExpiration(t *testing.T) {
+	m := newTestMemoryBackend(t)
+	m.Set("foo", "bar")
+	m.PExpire("foo", 50)
+
+	if ok, _ := m.Persist("foo"); ok != 1 {
+		t.Fatalf("Persist(foo) = %d, want 1", ok)
+	}
+	if ttl, _ := m.TTL("foo"); ttl != -1 {
+		t.Errorf("TTL(foo) after Persist = %d, want -1", ttl)
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if v, _ := m.Get("foo"); v != "bar" {
+		t.Errorf("Get(foo) = %q, want %q (persisted key must not expire)", v, "bar")
+	}
+}
+
+func TestSetClearsExistingTTL(t *testing.T) {
+	m := newTestMemoryBackend(t)
+	m.Set("foo", "bar")
+	m.PExpire("foo", 50)
+
+	m.Set("foo", "baz")
+	if ttl, _ := m.TTL("foo"); ttl != -1 {
+		t.Errorf("TTL(foo) after overwriting SET = %d, want -1 (no TTL)", ttl)
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if v, _ := m.Get("foo"); v != "baz" {
+		t.Errorf("Get(foo) = %q, want %q (overwritten value must not have inherited the old TTL)", v, "baz")
+	}
+}
+
+func TestActiveExpireCycleEvictsExpiredKeys(t *testing.T) {
+	m := newTestMemoryBackend(t)
+	for i := 0; i < 30; i++ {
+		key := string(rune('a' + i%26))
+		m.Set(key, "v")
+		m.PExpire(key, 1)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	m.activeExpireCycle()
+
+	m.mu.RLock()
+	remaining := len(m.expires)
+	m.mu.RUnlock()
+	if remaining != 0 {
+		t.Errorf("expires still tracks %d keys after the sweep, want 0", remaining)
+	}
+}
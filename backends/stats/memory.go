@@ -0,0 +1,354 @@
+package stats
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ValueType identifies the kind of data stored under a key, mirroring
+// redis's TYPE tags. Only string and int are reachable through the
+// commands registered today; hash, list, set and zset are reserved so
+// that HSET/LPUSH/SADD (and friends) can slot into the same keyspace
+// later without another storage rewrite.
+type ValueType int
+
+const (
+	TypeNone ValueType = iota
+	TypeString
+	TypeInt
+	TypeHash
+	TypeList
+	TypeSet
+	TypeZSet
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeHash:
+		return "hash"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeZSet:
+		return "zset"
+	default:
+		return "none"
+	}
+}
+
+// ErrWrongType is returned whenever a command targets a key that already
+// holds a value of a different type, matching redis's WRONGTYPE class of
+// errors.
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// Counter is a named, resettable integer tracked separately from the
+// typed value keyspace; COUNT/COUNTERS/FlushCounters never collide with
+// SET/GET/INCR's keys or types.
+type Counter struct {
+	Name  string
+	Count int
+}
+
+type entry struct {
+	kind ValueType
+	i    int
+	s    string
+}
+
+// newValueEntry builds the entry SET/SETNX store for val, encoding it as
+// TypeInt when it parses as a base-10 integer (so INCR/DECR can still
+// operate on it) and TypeString otherwise.
+func newValueEntry(val string) *entry {
+	if n, err := strconv.Atoi(val); err == nil {
+		return &entry{kind: TypeInt, i: n}
+	}
+	return &entry{kind: TypeString, s: val}
+}
+
+// String returns e's value the way GET reports it: redis always hands a
+// client back the string form of a key, regardless of whether it's
+// stored as the int-encoded fast path or a plain string.
+func (e *entry) String() string {
+	if e.kind == TypeInt {
+		return strconv.Itoa(e.i)
+	}
+	return e.s
+}
+
+type memoryBackend struct {
+	mu       sync.RWMutex
+	values   map[string]*entry
+	counters map[string]*Counter
+	expires  map[string]time.Time
+	versions map[string]int64
+	persist  Persistence
+}
+
+// NewMemoryBackend creates the in-memory Metrics implementation used by
+// StatsBackend by default. Durability is selected via PersistenceConfig;
+// with its default (PersistenceMemory) the backend behaves exactly as
+// before and state is lost on restart.
+func NewMemoryBackend() (Metrics, error) {
+	persist, err := newPersistence()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &memoryBackend{
+		values:   make(map[string]*entry),
+		counters: make(map[string]*Counter),
+		expires:  make(map[string]time.Time),
+		versions: make(map[string]int64),
+		persist:  persist,
+	}
+	if persist != nil {
+		if err := persist.Load(m.values, m.counters, m.expires); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// append records a mutating command to the persistence backend, if any.
+// Errors are best-effort: a write that already succeeded in memory isn't
+// rolled back because its durability log couldn't be appended to.
+func (m *memoryBackend) append(cmd string, args ...string) {
+	if m.persist != nil {
+		m.persist.Append(cmd, args...)
+	}
+}
+
+// snapshot serializes the current state through the persistence
+// backend, if any. Called from StatsBackend's existing flush ticker.
+func (m *memoryBackend) snapshot() error {
+	if m.persist == nil {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.persist.Snapshot(m.values, m.counters, m.expires)
+}
+
+// close releases the persistence backend, if any.
+func (m *memoryBackend) close() error {
+	if m.persist == nil {
+		return nil
+	}
+	return m.persist.Close()
+}
+
+func (m *memoryBackend) Version(name string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.versions[name], nil
+}
+
+// bumpVersionLocked records that name changed; must be called while
+// holding m.mu for writing.
+func (m *memoryBackend) bumpVersionLocked(name string) {
+	m.versions[name]++
+}
+
+func (m *memoryBackend) Type(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireIfNeededLocked(name)
+	e, ok := m.values[name]
+	if !ok {
+		return TypeNone.String(), nil
+	}
+	return e.kind.String(), nil
+}
+
+// expireIfNeededLocked deletes name (and its TTL) if it has expired. It
+// must be called while holding m.mu for writing, which is why every
+// lookup path below that calls it takes the full mutex rather than the
+// read side (Counters, which never expires anything, reads under RLock): a
+// GET can trigger a delete the same way it would in real redis's lazy
+// expiration.
+func (m *memoryBackend) expireIfNeededLocked(name string) bool {
+	exp, ok := m.expires[name]
+	if !ok || time.Now().Before(exp) {
+		return false
+	}
+	delete(m.values, name)
+	delete(m.counters, name)
+	delete(m.expires, name)
+	m.bumpVersionLocked(name)
+	return true
+}
+
+// intEntry fetches the entry for name, enforcing that it is either
+// absent or already an int, returning ErrWrongType otherwise.
+func (m *memoryBackend) intEntry(name string) (*entry, error) {
+	m.expireIfNeededLocked(name)
+	e, ok := m.values[name]
+	if !ok {
+		return nil, nil
+	}
+	if e.kind != TypeInt {
+		return nil, ErrWrongType
+	}
+	return e, nil
+}
+
+func (m *memoryBackend) Counter(name string) (int, error) {
+	return m.CounterBy(name, 1)
+}
+
+func (m *memoryBackend) CounterBy(name string, count int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireIfNeededLocked(name)
+	c, ok := m.counters[name]
+	if !ok {
+		c = &Counter{Name: name}
+		m.counters[name] = c
+	}
+	c.Count += count
+	m.bumpVersionLocked(name)
+	m.append("COUNTER", name, strconv.Itoa(count))
+	return c.Count, nil
+}
+
+func (m *memoryBackend) FlushCounters() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range m.counters {
+		m.bumpVersionLocked(name)
+	}
+	m.counters = make(map[string]*Counter)
+	return nil
+}
+
+func (m *memoryBackend) Counters() (map[string]*Counter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make(map[string]*Counter, len(m.counters))
+	for k, v := range m.counters {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m *memoryBackend) Incr(name string) (int, error) {
+	return m.IncrBy(name, 1)
+}
+
+func (m *memoryBackend) IncrBy(name string, count int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.intEntry(name)
+	if err != nil {
+		return 0, err
+	}
+	if e == nil {
+		e = &entry{kind: TypeInt}
+		m.values[name] = e
+	}
+	e.i += count
+	m.bumpVersionLocked(name)
+	m.append("INCR", name, strconv.Itoa(count))
+	return e.i, nil
+}
+
+func (m *memoryBackend) Decr(name string) (int, error) {
+	return m.DecrBy(name, 1)
+}
+
+func (m *memoryBackend) DecrBy(name string, count int) (int, error) {
+	return m.IncrBy(name, -count)
+}
+
+func (m *memoryBackend) Del(name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.expireIfNeededLocked(name) {
+		return 0, nil
+	}
+
+	deleted := 0
+	if _, ok := m.values[name]; ok {
+		delete(m.values, name)
+		deleted = 1
+	}
+	if _, ok := m.counters[name]; ok {
+		delete(m.counters, name)
+		deleted = 1
+	}
+	delete(m.expires, name)
+	if deleted == 1 {
+		m.bumpVersionLocked(name)
+		m.append("DEL", name)
+	}
+	return deleted, nil
+}
+
+func (m *memoryBackend) Exists(name string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireIfNeededLocked(name)
+	if _, ok := m.values[name]; ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Get returns the string form of name's value, matching redis's GET
+// regardless of whether it was stored via a numeric or plain-string SET.
+// It only fails with ErrWrongType once a non-string kind (hash, list,
+// set, zset) is reachable through some other command.
+func (m *memoryBackend) Get(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireIfNeededLocked(name)
+	e, ok := m.values[name]
+	if !ok {
+		return "", nil
+	}
+	if e.kind != TypeInt && e.kind != TypeString {
+		return "", ErrWrongType
+	}
+	return e.String(), nil
+}
+
+func (m *memoryBackend) Set(name string, val string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[name] = newValueEntry(val)
+	delete(m.expires, name)
+	m.bumpVersionLocked(name)
+	m.append("SET", name, val)
+	return val, nil
+}
+
+func (m *memoryBackend) SetNx(name string, val string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireIfNeededLocked(name)
+	if _, ok := m.values[name]; ok {
+		return 0, nil
+	}
+	m.values[name] = newValueEntry(val)
+	m.bumpVersionLocked(name)
+	m.append("SETNX", name, val)
+	return 1, nil
+}
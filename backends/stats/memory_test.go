@@ -0,0 +1,32 @@
+package stats
+
+import "testing"
+
+func TestTypeReportsStringAndInt(t *testing.T) {
+	m := newTestMemoryBackend(t)
+	m.Set("str", "hello")
+	m.Set("num", "42")
+
+	if typ, _ := m.Type("str"); typ != "string" {
+		t.Errorf("Type(str) = %q, want %q", typ, "string")
+	}
+	if typ, _ := m.Type("num"); typ != "int" {
+		t.Errorf("Type(num) = %q, want %q", typ, "int")
+	}
+}
+
+func TestTypeMissingKeyIsNone(t *testing.T) {
+	m := newTestMemoryBackend(t)
+	if typ, _ := m.Type("missing"); typ != "none" {
+		t.Errorf("Type(missing) = %q, want %q", typ, "none")
+	}
+}
+
+func TestIncrAgainstStringIsWrongType(t *testing.T) {
+	m := newTestMemoryBackend(t)
+	m.Set("str", "hello")
+
+	if _, err := m.Incr("str"); err != ErrWrongType {
+		t.Errorf("Incr(str) = %v, want ErrWrongType", err)
+	}
+}
@@ -2,6 +2,7 @@ package stats
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/nyxtom/broadcast/server"
@@ -24,10 +25,24 @@ type Metrics interface {
 
 	Exists(name string) (int, error)
 
-	Get(name string) (int, error)
+	Get(name string) (string, error)
 
-	Set(name string, value int) (int, error)
-	SetNx(name string, value int) (int, error)
+	Set(name string, value string) (string, error)
+	SetNx(name string, value string) (int, error)
+
+	Type(name string) (string, error)
+
+	Expire(name string, seconds int) (int, error)
+	PExpire(name string, ms int) (int, error)
+	TTL(name string) (int, error)
+	PTTL(name string) (int, error)
+	Persist(name string) (int, error)
+
+	// Version returns a monotonically increasing counter bumped every
+	// time name is mutated (set, incremented, deleted, expired, ...),
+	// used by WATCH to detect a key changing out from under a
+	// transaction.
+	Version(name string) (int64, error)
 }
 
 type StatsBackend struct {
@@ -47,84 +62,109 @@ func (stats *StatsBackend) FlushInt(i int, err error, client *server.NetworkClie
 	return nil
 }
 
+func (stats *StatsBackend) FlushString(s string, err error, client *server.NetworkClient) error {
+	if err != nil {
+		return err
+	}
+	client.WriteString(s)
+	client.Flush()
+	return nil
+}
+
+// parseIntArg parses a single RESP argument as a base-10 integer,
+// surfacing the same class of error real redis returns for e.g.
+// `EXPIRE key notanumber`.
+func parseIntArg(b []byte) (int, error) {
+	n, err := strconv.Atoi(string(b))
+	if err != nil {
+		return 0, errors.New("ERR value is not an integer or out of range")
+	}
+	return n, nil
+}
+
 func (stats *StatsBackend) Set(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) < 2 {
 		client.WriteError(errors.New("SET takes at least 2 parameters (i.e. key to set and value to set to)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
-		value := d[1].(int64)
-		i, err := stats.mem.Set(key, int(value))
-		return stats.FlushInt(i, err, client)
+		key := string(d[0])
+		value := string(d[1])
+		v, err := stats.mem.Set(key, value)
+		return stats.FlushString(v, err, client)
 	}
 }
 
 func (stats *StatsBackend) SetNx(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) < 2 {
 		client.WriteError(errors.New("SETNX takes at least 2 parameters (i.e. key to set and value to set to, if not already set)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
-		value := d[1].(int64)
-		i, err := stats.mem.SetNx(key, int(value))
+		key := string(d[0])
+		value := string(d[1])
+		i, err := stats.mem.SetNx(key, value)
 		return stats.FlushInt(i, err, client)
 	}
 }
 
 func (stats *StatsBackend) Get(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) == 0 {
 		client.WriteError(errors.New("GET takes at least 1 parameter (i.e. key to get)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
-		i, err := stats.mem.Get(key)
-		return stats.FlushInt(i, err, client)
+		key := string(d[0])
+		v, err := stats.mem.Get(key)
+		return stats.FlushString(v, err, client)
 	}
 }
 
 func (stats *StatsBackend) Exists(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) == 0 {
 		client.WriteError(errors.New("EXISTS takes at least 1 parameter (i.e. key to find)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
+		key := string(d[0])
 		i, err := stats.mem.Exists(key)
 		return stats.FlushInt(i, err, client)
 	}
 }
 
 func (stats *StatsBackend) Del(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) == 0 {
 		client.WriteError(errors.New("DEL takes at least 1 parameter (i.e. key to delete)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
+		key := string(d[0])
 		i, err := stats.mem.Del(key)
 		return stats.FlushInt(i, err, client)
 	}
 }
 
 func (stats *StatsBackend) Incr(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) == 0 {
 		client.WriteError(errors.New("INCR takes at least 1 parameter (i.e. key to increment)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
+		key := string(d[0])
 		values := d[1:]
 		if len(values) > 0 {
-			value := int(values[0].(int64))
+			value, err := parseIntArg(values[0])
+			if err != nil {
+				client.WriteError(err)
+				client.Flush()
+				return nil
+			}
 			i, err := stats.mem.IncrBy(key, value)
 			return stats.FlushInt(i, err, client)
 		} else {
@@ -135,16 +175,21 @@ func (stats *StatsBackend) Incr(data interface{}, client *server.NetworkClient)
 }
 
 func (stats *StatsBackend) Decr(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) == 0 {
 		client.WriteError(errors.New("DECR takes at least 1 parameter (i.e. key to increment)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
+		key := string(d[0])
 		values := d[1:]
 		if len(values) > 0 {
-			value := int(values[0].(int64))
+			value, err := parseIntArg(values[0])
+			if err != nil {
+				client.WriteError(err)
+				client.Flush()
+				return nil
+			}
 			i, err := stats.mem.DecrBy(key, value)
 			return stats.FlushInt(i, err, client)
 		} else {
@@ -155,16 +200,21 @@ func (stats *StatsBackend) Decr(data interface{}, client *server.NetworkClient)
 }
 
 func (stats *StatsBackend) Count(data interface{}, client *server.NetworkClient) error {
-	d, _ := data.([]interface{})
+	d, _ := data.([][]byte)
 	if len(d) == 0 {
 		client.WriteError(errors.New("COUNTER takes at least 1 parameter (i.e. key to increment)"))
 		client.Flush()
 		return nil
 	} else {
-		key := d[0].(string)
+		key := string(d[0])
 		values := d[1:]
 		if len(values) > 0 {
-			value := int(values[0].(int64))
+			value, err := parseIntArg(values[0])
+			if err != nil {
+				client.WriteError(err)
+				client.Flush()
+				return nil
+			}
 			i, err := stats.mem.CounterBy(key, value)
 			return stats.FlushInt(i, err, client)
 		} else {
@@ -174,6 +224,106 @@ func (stats *StatsBackend) Count(data interface{}, client *server.NetworkClient)
 	}
 }
 
+func (stats *StatsBackend) Type(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) == 0 {
+		client.WriteError(errors.New("TYPE takes at least 1 parameter (i.e. key to check)"))
+		client.Flush()
+		return nil
+	}
+
+	key := string(d[0])
+	t, err := stats.mem.Type(key)
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	client.WriteString(t)
+	client.Flush()
+	return nil
+}
+
+func (stats *StatsBackend) Expire(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) < 2 {
+		client.WriteError(errors.New("EXPIRE takes 2 parameters (i.e. key and seconds until expiration)"))
+		client.Flush()
+		return nil
+	}
+	key := string(d[0])
+	seconds, err := parseIntArg(d[1])
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+	i, err := stats.mem.Expire(key, seconds)
+	return stats.FlushInt(i, err, client)
+}
+
+func (stats *StatsBackend) PExpire(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) < 2 {
+		client.WriteError(errors.New("PEXPIRE takes 2 parameters (i.e. key and milliseconds until expiration)"))
+		client.Flush()
+		return nil
+	}
+	key := string(d[0])
+	ms, err := parseIntArg(d[1])
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+	i, err := stats.mem.PExpire(key, ms)
+	return stats.FlushInt(i, err, client)
+}
+
+func (stats *StatsBackend) TTL(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) == 0 {
+		client.WriteError(errors.New("TTL takes at least 1 parameter (i.e. key to check)"))
+		client.Flush()
+		return nil
+	}
+	key := string(d[0])
+	i, err := stats.mem.TTL(key)
+	return stats.FlushInt(i, err, client)
+}
+
+func (stats *StatsBackend) PTTL(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) == 0 {
+		client.WriteError(errors.New("PTTL takes at least 1 parameter (i.e. key to check)"))
+		client.Flush()
+		return nil
+	}
+	key := string(d[0])
+	i, err := stats.mem.PTTL(key)
+	return stats.FlushInt(i, err, client)
+}
+
+func (stats *StatsBackend) Persist(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) == 0 {
+		client.WriteError(errors.New("PERSIST takes at least 1 parameter (i.e. key to persist)"))
+		client.Flush()
+		return nil
+	}
+	key := string(d[0])
+	i, err := stats.mem.Persist(key)
+	return stats.FlushInt(i, err, client)
+}
+
+// Version exposes the underlying Metrics' per-key version counter so
+// other packages (notably RedisProtocol's WATCH support) can detect a
+// key changing without depending on the memoryBackend directly.
+func (stats *StatsBackend) Version(name string) (int64, error) {
+	return stats.mem.Version(name)
+}
+
 func (stats *StatsBackend) Counters(data interface{}, client *server.NetworkClient) error {
 	results, err := stats.mem.Counters()
 	if err != nil {
@@ -204,8 +354,14 @@ func RegisterBackend(app *server.BroadcastServer) (server.Backend, error) {
 		server.Command{"DEL", "Deletes a key from the values or counters list or both.", "DEL key"},
 		server.Command{"EXISTS", "Determines if the given key exists from the values.", "EXISTS key"},
 		server.Command{"GET", "Gets the specified key from the values.", "GET key"},
-		server.Command{"SET", "Sets the specified key to the specified value in values.", "SET key 1234"},
-		server.Command{"SETNX", "Sets the specified key to the given value only if the key is not already set.", "SETNX key 1234"},
+		server.Command{"SET", "Sets the specified key to the specified value (int or string) in values.", "SET key hello"},
+		server.Command{"SETNX", "Sets the specified key to the given value (int or string) only if the key is not already set.", "SETNX key hello"},
+		server.Command{"TYPE", "Returns the type stored at the given key, or \"none\" if it doesn't exist.", "TYPE key"},
+		server.Command{"EXPIRE", "Sets a key's time to live in seconds.", "EXPIRE key 60"},
+		server.Command{"PEXPIRE", "Sets a key's time to live in milliseconds.", "PEXPIRE key 60000"},
+		server.Command{"TTL", "Returns the remaining time to live of a key in seconds, -1 if it has none and -2 if it doesn't exist.", "TTL key"},
+		server.Command{"PTTL", "Returns the remaining time to live of a key in milliseconds, -1 if it has none and -2 if it doesn't exist.", "PTTL key"},
+		server.Command{"PERSIST", "Removes the expiration from a key, making it persistent.", "PERSIST key"},
 	}
 	commands := []server.Handler{
 		backend.Count,
@@ -217,6 +373,12 @@ func RegisterBackend(app *server.BroadcastServer) (server.Backend, error) {
 		backend.Get,
 		backend.Set,
 		backend.SetNx,
+		backend.Type,
+		backend.Expire,
+		backend.PExpire,
+		backend.TTL,
+		backend.PTTL,
+		backend.Persist,
 	}
 
 	for i, _ := range commandHelp {
@@ -226,6 +388,21 @@ func RegisterBackend(app *server.BroadcastServer) (server.Backend, error) {
 	return backend, nil
 }
 
+// persistentMetrics is implemented by Metrics backends (namely
+// memoryBackend) that can serialize their state and release any
+// underlying resources; it's checked with a type assertion since not
+// every Metrics implementation needs durability.
+type persistentMetrics interface {
+	snapshot() error
+	close() error
+}
+
+// expiringMetrics is implemented by Metrics backends that need an
+// active expiration sweeper alongside the flush ticker.
+type expiringMetrics interface {
+	runExpireSweeper(interval time.Duration, quit chan struct{})
+}
+
 func (stats *StatsBackend) Load() error {
 	stats.quit = make(chan struct{})
 	stats.timer = time.NewTicker(5 * time.Second)
@@ -234,16 +411,26 @@ func (stats *StatsBackend) Load() error {
 			select {
 			case <-stats.timer.C:
 				stats.mem.FlushCounters()
+				if p, ok := stats.mem.(persistentMetrics); ok {
+					p.snapshot()
+				}
 			case <-stats.quit:
 				stats.timer.Stop()
 				return
 			}
 		}
 	}()
+
+	if e, ok := stats.mem.(expiringMetrics); ok {
+		go e.runExpireSweeper(100*time.Millisecond, stats.quit)
+	}
 	return nil
 }
 
 func (stats *StatsBackend) Unload() error {
 	close(stats.quit)
+	if p, ok := stats.mem.(persistentMetrics); ok {
+		return p.close()
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,461 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PersistenceMode selects how a StatsBackend keeps its data across
+// restarts.
+type PersistenceMode string
+
+const (
+	PersistenceMemory      PersistenceMode = "memory"
+	PersistenceAOF         PersistenceMode = "aof"
+	PersistenceSnapshot    PersistenceMode = "snapshot"
+	PersistenceAOFSnapshot PersistenceMode = "aof+snapshot"
+)
+
+// PersistenceConfig controls how RegisterBackend wires up durability.
+// It's a package-level var, set before RegisterBackend runs, rather than
+// threaded through server.BroadcastServer (which has no notion of
+// per-backend configuration today).
+var PersistenceConfig = struct {
+	Mode        PersistenceMode
+	Dir         string
+	SyncEveryMs int
+}{
+	Mode:        PersistenceMemory,
+	SyncEveryMs: 200,
+}
+
+// Persistence is implemented by the durability strategies that can back
+// a memoryBackend: an append-only command log, a periodic full
+// snapshot, or both together.
+type Persistence interface {
+	// Append records a single mutating command so it can be replayed on
+	// restart.
+	Append(cmd string, args ...string) error
+
+	// Snapshot serializes the full counter/value/expiration state to
+	// durable storage, superseding everything appended before it.
+	Snapshot(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error
+
+	// Load replays durable state back into values/counters/expires.
+	Load(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error
+
+	Close() error
+}
+
+// newPersistence builds the Persistence implementation selected by
+// PersistenceConfig.Mode, or nil for PersistenceMemory.
+func newPersistence() (Persistence, error) {
+	switch PersistenceConfig.Mode {
+	case "", PersistenceMemory:
+		return nil, nil
+	case PersistenceAOF:
+		return newAOFWriter(PersistenceConfig.Dir, PersistenceConfig.SyncEveryMs)
+	case PersistenceSnapshot:
+		return newSnapshotWriter(PersistenceConfig.Dir)
+	case PersistenceAOFSnapshot:
+		aof, err := newAOFWriter(PersistenceConfig.Dir, PersistenceConfig.SyncEveryMs)
+		if err != nil {
+			return nil, err
+		}
+		snap, err := newSnapshotWriter(PersistenceConfig.Dir)
+		if err != nil {
+			return nil, err
+		}
+		return &aofSnapshotWriter{aof: aof, snapshot: snap}, nil
+	default:
+		return nil, fmt.Errorf("stats: unknown persistence mode %q", PersistenceConfig.Mode)
+	}
+}
+
+// mutatingCommands are replayed from the AOF; read-only commands like
+// GET or EXISTS never reach Append.
+var mutatingCommands = map[string]bool{
+	"SET":       true,
+	"SETNX":     true,
+	"INCR":      true,
+	"DECR":      true,
+	"COUNTER":   true,
+	"DEL":       true,
+	"PEXPIREAT": true,
+	"PERSIST":   true,
+}
+
+// aofWriter appends one line per mutating command, flushing either
+// synchronously (syncEveryMs == 0) or on a ticker.
+type aofWriter struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	w    *bufio.Writer
+
+	syncEvery time.Duration
+	quit      chan struct{}
+}
+
+func newAOFWriter(dir string, syncEveryMs int) (*aofWriter, error) {
+	if dir == "" {
+		dir = "."
+	}
+	path := filepath.Join(dir, "stats.aof")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &aofWriter{
+		path:      path,
+		f:         f,
+		w:         bufio.NewWriter(f),
+		syncEvery: time.Duration(syncEveryMs) * time.Millisecond,
+		quit:      make(chan struct{}),
+	}
+	if a.syncEvery > 0 {
+		go a.syncLoop()
+	}
+	return a, nil
+}
+
+func (a *aofWriter) syncLoop() {
+	t := time.NewTicker(a.syncEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			a.mu.Lock()
+			a.w.Flush()
+			a.f.Sync()
+			a.mu.Unlock()
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+// writeRecord encodes fields as a sequence of length-prefixed chunks
+// (`<byte-length>:<bytes>`, space separated, newline terminated) rather
+// than naively space-joining them, so a value containing whitespace
+// round-trips through the AOF intact instead of being cut at the first
+// space on replay.
+func writeRecord(w *bufio.Writer, fields []string) error {
+	for i, f := range fields {
+		if i > 0 {
+			if err := w.WriteByte(' '); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%d:%s", len(f), f); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte('\n')
+}
+
+// readRecord decodes one record written by writeRecord, returning
+// io.EOF once the reader is exhausted between records.
+func readRecord(r *bufio.Reader) ([]string, error) {
+	var fields []string
+	for {
+		lenStr, err := r.ReadString(':')
+		if err != nil {
+			if err == io.EOF && lenStr == "" {
+				if len(fields) == 0 {
+					return nil, io.EOF
+				}
+				return fields, nil
+			}
+			return nil, err
+		}
+
+		n, err := strconv.Atoi(strings.TrimSuffix(lenStr, ":"))
+		if err != nil {
+			return nil, fmt.Errorf("stats: corrupt AOF record length %q", lenStr)
+		}
+
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		fields = append(fields, string(buf))
+
+		sep, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if sep == '\n' {
+			return fields, nil
+		}
+	}
+}
+
+func (a *aofWriter) Append(cmd string, args ...string) error {
+	if !mutatingCommands[cmd] {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := writeRecord(a.w, append([]string{cmd}, args...)); err != nil {
+		return err
+	}
+	if a.syncEvery == 0 {
+		if err := a.w.Flush(); err != nil {
+			return err
+		}
+		return a.f.Sync()
+	}
+	return nil
+}
+
+func (a *aofWriter) Snapshot(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error {
+	return nil
+}
+
+func (a *aofWriter) Load(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		fields, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		replayRecord(fields, values, counters, expires)
+	}
+}
+
+// replayRecord applies one previously-appended AOF record directly to
+// the in-memory maps, bypassing StatsBackend so replay never re-appends.
+func replayRecord(fields []string, values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) {
+	if len(fields) < 2 {
+		return
+	}
+
+	cmd, key, rest := fields[0], fields[1], fields[2:]
+	switch cmd {
+	case "SET", "SETNX":
+		if len(rest) == 0 {
+			return
+		}
+		values[key] = newValueEntry(rest[0])
+		delete(expires, key)
+	case "DEL":
+		delete(values, key)
+		delete(counters, key)
+		delete(expires, key)
+	case "INCR", "DECR", "COUNTER":
+		delta := 1
+		if len(rest) > 0 {
+			if v, err := strconv.Atoi(rest[0]); err == nil {
+				delta = v
+			}
+		}
+		if cmd == "DECR" {
+			delta = -delta
+		}
+		if cmd == "COUNTER" {
+			c, ok := counters[key]
+			if !ok {
+				c = &Counter{Name: key}
+				counters[key] = c
+			}
+			c.Count += delta
+			return
+		}
+		e, ok := values[key]
+		if !ok {
+			e = &entry{kind: TypeInt}
+			values[key] = e
+		}
+		e.i += delta
+	case "PEXPIREAT":
+		if len(rest) == 0 {
+			return
+		}
+		ms, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return
+		}
+		expires[key] = time.UnixMilli(ms)
+	case "PERSIST":
+		delete(expires, key)
+	}
+}
+
+func (a *aofWriter) Close() error {
+	close(a.quit)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.w.Flush()
+	return a.f.Close()
+}
+
+// snapshotEntry and snapshotFile are the JSON-friendly DTOs for entry
+// and Counter, whose own fields aren't (or shouldn't need to be)
+// exported just to be persisted.
+type snapshotEntry struct {
+	Kind ValueType
+	I    int
+	S    string
+}
+
+type snapshotFile struct {
+	Values   map[string]snapshotEntry
+	Counters map[string]*Counter
+	// Expires holds each expiring key's deadline as Unix milliseconds
+	// rather than time.Time, so a snapshot taken on one machine's clock
+	// still means the same instant after being loaded on another's.
+	Expires map[string]int64
+}
+
+// snapshotWriter serializes the full value/counter map to disk, writing
+// to a temp file and renaming over the previous snapshot so a crash
+// mid-write never leaves a corrupt file in place.
+type snapshotWriter struct {
+	mu   sync.Mutex
+	path string
+}
+
+func newSnapshotWriter(dir string) (*snapshotWriter, error) {
+	if dir == "" {
+		dir = "."
+	}
+	return &snapshotWriter{path: filepath.Join(dir, "stats.snapshot")}, nil
+}
+
+func (s *snapshotWriter) Append(cmd string, args ...string) error {
+	return nil
+}
+
+func (s *snapshotWriter) Snapshot(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := snapshotFile{
+		Values:   make(map[string]snapshotEntry, len(values)),
+		Counters: counters,
+		Expires:  make(map[string]int64, len(expires)),
+	}
+	for k, v := range values {
+		out.Values[k] = snapshotEntry{Kind: v.kind, I: v.i, S: v.s}
+	}
+	for k, v := range expires {
+		out.Expires[k] = v.UnixMilli()
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *snapshotWriter) Load(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var in snapshotFile
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	for k, v := range in.Values {
+		values[k] = &entry{kind: v.Kind, i: v.I, s: v.S}
+	}
+	for k, v := range in.Counters {
+		counters[k] = v
+	}
+	for k, v := range in.Expires {
+		expires[k] = time.UnixMilli(v)
+	}
+	return nil
+}
+
+func (s *snapshotWriter) Close() error {
+	return nil
+}
+
+// aofSnapshotWriter replays the snapshot first, then the AOF tail
+// written since, and keeps appending to the AOF between snapshots.
+type aofSnapshotWriter struct {
+	aof      *aofWriter
+	snapshot *snapshotWriter
+}
+
+func (c *aofSnapshotWriter) Append(cmd string, args ...string) error {
+	return c.aof.Append(cmd, args...)
+}
+
+func (c *aofSnapshotWriter) Snapshot(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error {
+	if err := c.snapshot.Snapshot(values, counters, expires); err != nil {
+		return err
+	}
+	return c.aof.truncate()
+}
+
+func (c *aofSnapshotWriter) Load(values map[string]*entry, counters map[string]*Counter, expires map[string]time.Time) error {
+	if err := c.snapshot.Load(values, counters, expires); err != nil {
+		return err
+	}
+	return c.aof.Load(values, counters, expires)
+}
+
+func (c *aofSnapshotWriter) Close() error {
+	c.aof.Close()
+	return c.snapshot.Close()
+}
+
+// truncate clears the AOF once its contents have been folded into a
+// fresh snapshot.
+func (a *aofWriter) truncate() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+	if err := a.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := a.f.Seek(0, 0)
+	return err
+}
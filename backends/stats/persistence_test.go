@@ -0,0 +1,140 @@
+package stats
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAOFRoundTripPreservesWhitespaceInValues(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := newAOFWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("newAOFWriter: %v", err)
+	}
+	if err := a.Append("SET", "greeting", "hello world"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := a.Append("INCR", "counter", "5"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	values := make(map[string]*entry)
+	counters := make(map[string]*Counter)
+	expires := make(map[string]time.Time)
+	reload, err := newAOFWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("newAOFWriter (reload): %v", err)
+	}
+	defer reload.Close()
+	if err := reload.Load(values, counters, expires); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	e, ok := values["greeting"]
+	if !ok {
+		t.Fatalf("greeting missing after replay")
+	}
+	if got := e.String(); got != "hello world" {
+		t.Errorf("greeting = %q, want %q", got, "hello world")
+	}
+
+	e, ok = values["counter"]
+	if !ok || e.i != 5 {
+		t.Errorf("counter = %+v, want i=5", e)
+	}
+}
+
+func TestAOFRoundTripReplaysExpirations(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := newAOFWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("newAOFWriter: %v", err)
+	}
+	if err := a.Append("SET", "foo", "bar"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	deadline := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	if err := a.Append("PEXPIREAT", "foo", strconv.FormatInt(deadline.UnixMilli(), 10)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := a.Append("SET", "baz", "qux"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := a.Append("PEXPIREAT", "baz", strconv.FormatInt(deadline.UnixMilli(), 10)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := a.Append("PERSIST", "baz"); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	values := make(map[string]*entry)
+	counters := make(map[string]*Counter)
+	expires := make(map[string]time.Time)
+	reload, err := newAOFWriter(dir, 0)
+	if err != nil {
+		t.Fatalf("newAOFWriter (reload): %v", err)
+	}
+	defer reload.Close()
+	if err := reload.Load(values, counters, expires); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got, ok := expires["foo"]; !ok || !got.Equal(deadline) {
+		t.Errorf("foo's expiration = %v, %v, want %v, true", got, ok, deadline)
+	}
+	if _, ok := expires["baz"]; ok {
+		t.Errorf("baz still has an expiration after a replayed PERSIST")
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := newSnapshotWriter(dir)
+	if err != nil {
+		t.Fatalf("newSnapshotWriter: %v", err)
+	}
+
+	values := map[string]*entry{
+		"greeting": newValueEntry("hello world"),
+		"n":        newValueEntry("42"),
+	}
+	counters := map[string]*Counter{
+		"hits": {Name: "hits", Count: 3},
+	}
+	expires := map[string]time.Time{
+		"n": time.Now().Add(time.Hour).Truncate(time.Millisecond),
+	}
+	if err := s.Snapshot(values, counters, expires); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	loadedValues := make(map[string]*entry)
+	loadedCounters := make(map[string]*Counter)
+	loadedExpires := make(map[string]time.Time)
+	if err := s.Load(loadedValues, loadedCounters, loadedExpires); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := loadedValues["greeting"].String(); got != "hello world" {
+		t.Errorf("greeting = %q, want %q", got, "hello world")
+	}
+	if got := loadedValues["n"].String(); got != "42" {
+		t.Errorf("n = %q, want %q", got, "42")
+	}
+	if c := loadedCounters["hits"]; c == nil || c.Count != 3 {
+		t.Errorf("hits = %+v, want Count=3", c)
+	}
+	if got := loadedExpires["n"]; !got.Equal(expires["n"]) {
+		t.Errorf("n's expiration = %v, want %v", got, expires["n"])
+	}
+}
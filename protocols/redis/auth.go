@@ -0,0 +1,122 @@
+package redisProtocol
+
+import (
+	"errors"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+var errNoAuth = errors.New("NOAUTH Authentication required")
+var errAuthFailed = errors.New("ERR invalid password")
+var errNoACLPerm = errors.New("NOPERM this user has no permissions to run this command")
+
+// commandsWithoutAuth may always be run on an unauthenticated connection,
+// mirroring what redis itself exempts before a client has AUTHed.
+var commandsWithoutAuth = map[string]bool{
+	"AUTH":  true,
+	"HELLO": true,
+	"QUIT":  true,
+	"PING":  true,
+}
+
+// ACLUser is a single entry of the simple ACL map: a password and the
+// set of commands that user may run. A nil or empty AllowedCommands
+// means the user may run anything, same as redis's `allcommands`.
+type ACLUser struct {
+	Password        string
+	AllowedCommands map[string]bool
+}
+
+// AuthConfig holds either a single `requirepass`-style shared password
+// or a small ACL of named users, matched against incoming AUTH commands.
+// It is attached to a RedisProtocol instance at startup (rather than
+// living on server.BroadcastContext) so auth stays a concern of this
+// protocol package.
+type AuthConfig struct {
+	RequirePass string
+	Users       map[string]*ACLUser
+}
+
+func (c *AuthConfig) required() bool {
+	return c != nil && (c.RequirePass != "" || len(c.Users) > 0)
+}
+
+// SetAuthConfig wires up the AUTH/ACL configuration for connections
+// handled by this protocol instance. Passing nil disables auth entirely.
+func (p *RedisProtocol) SetAuthConfig(cfg *AuthConfig) {
+	p.auth = cfg
+}
+
+// authorize checks whether cmd may run given the connection's current
+// auth state, returning errNoAuth/errNoACLPerm as appropriate.
+func (p *RedisProtocol) authorize(cmd string, state *connState) error {
+	if !p.auth.required() || commandsWithoutAuth[cmd] {
+		return nil
+	}
+	if !state.authenticated {
+		return errNoAuth
+	}
+	if p.auth.Users == nil {
+		return nil
+	}
+	user, ok := p.auth.Users[state.user]
+	if !ok {
+		return errNoAuth
+	}
+	if len(user.AllowedCommands) > 0 && !user.AllowedCommands[cmd] {
+		return errNoACLPerm
+	}
+	return nil
+}
+
+// handleAuth implements `AUTH password` (requirepass mode) and
+// `AUTH user password` (ACL mode).
+func (p *RedisProtocol) handleAuth(args [][]byte, client server.ProtocolClient) error {
+	state := stateFor(client)
+
+	if !p.auth.required() {
+		return errors.New("ERR Client sent AUTH, but no password is set")
+	}
+
+	var user, pass string
+	switch len(args) {
+	case 1:
+		pass = string(args[0])
+	case 2:
+		user, pass = string(args[0]), string(args[1])
+	default:
+		return errors.New("ERR wrong number of arguments for 'auth' command")
+	}
+
+	if p.auth.Users != nil {
+		u, ok := p.auth.Users[user]
+		if !ok || u.Password != pass {
+			return errAuthFailed
+		}
+		state.user = user
+	} else {
+		if pass != p.auth.RequirePass {
+			return errAuthFailed
+		}
+	}
+
+	state.authenticated = true
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// handlePing implements PING, which commandsWithoutAuth and
+// pubsubCommands both already treat as exempt from auth and
+// subscribe-mode restrictions; without this it fell through to
+// errCmdNotFound since no command handler registered it.
+func (p *RedisProtocol) handlePing(args [][]byte, client server.ProtocolClient) error {
+	if len(args) > 0 {
+		client.WriteBytes(args[0])
+		client.Flush()
+		return nil
+	}
+	client.WriteString("PONG")
+	client.Flush()
+	return nil
+}
@@ -0,0 +1,64 @@
+package redisProtocol
+
+import "testing"
+
+func TestAuthorizeNoConfigAllowsEverything(t *testing.T) {
+	p := &RedisProtocol{}
+	if err := p.authorize("GET", &connState{}); err != nil {
+		t.Errorf("authorize with no AuthConfig = %v, want nil", err)
+	}
+}
+
+func TestAuthorizeRequirePassNeedsAuth(t *testing.T) {
+	p := &RedisProtocol{}
+	p.SetAuthConfig(&AuthConfig{RequirePass: "secret"})
+
+	if err := p.authorize("GET", &connState{}); err != errNoAuth {
+		t.Errorf("authorize(unauthenticated) = %v, want errNoAuth", err)
+	}
+	if err := p.authorize("AUTH", &connState{}); err != nil {
+		t.Errorf("authorize(AUTH, unauthenticated) = %v, want nil (exempt)", err)
+	}
+	if err := p.authorize("GET", &connState{authenticated: true}); err != nil {
+		t.Errorf("authorize(authenticated) = %v, want nil", err)
+	}
+}
+
+func TestAuthorizeACLUnknownUser(t *testing.T) {
+	p := &RedisProtocol{}
+	p.SetAuthConfig(&AuthConfig{Users: map[string]*ACLUser{
+		"alice": {Password: "pw"},
+	}})
+
+	state := &connState{authenticated: true, user: "bob"}
+	if err := p.authorize("GET", state); err != errNoAuth {
+		t.Errorf("authorize(unknown ACL user) = %v, want errNoAuth", err)
+	}
+}
+
+func TestAuthorizeACLRestrictedCommands(t *testing.T) {
+	p := &RedisProtocol{}
+	p.SetAuthConfig(&AuthConfig{Users: map[string]*ACLUser{
+		"alice": {Password: "pw", AllowedCommands: map[string]bool{"GET": true}},
+	}})
+
+	state := &connState{authenticated: true, user: "alice"}
+	if err := p.authorize("GET", state); err != nil {
+		t.Errorf("authorize(allowed command) = %v, want nil", err)
+	}
+	if err := p.authorize("SET", state); err != errNoACLPerm {
+		t.Errorf("authorize(disallowed command) = %v, want errNoACLPerm", err)
+	}
+}
+
+func TestAuthorizeACLUserWithNoAllowedCommandsCanRunAnything(t *testing.T) {
+	p := &RedisProtocol{}
+	p.SetAuthConfig(&AuthConfig{Users: map[string]*ACLUser{
+		"alice": {Password: "pw"},
+	}})
+
+	state := &connState{authenticated: true, user: "alice"}
+	if err := p.authorize("FLUSHALL", state); err != nil {
+		t.Errorf("authorize(user with no AllowedCommands) = %v, want nil", err)
+	}
+}
@@ -0,0 +1,63 @@
+package redisProtocol
+
+import (
+	"net"
+	"sync"
+)
+
+// batchingConn wraps a connection's net.Conn so RunClient can hold
+// writes in memory while it drains a run of already-pipelined commands,
+// then release them to the socket in one Write call instead of paying a
+// syscall per reply. Outside of a hold/release span (notably the
+// pubsub pump goroutine pushing an async message) every Write passes
+// straight through, so nothing but RunClient's own command loop ever
+// waits on a release. Reads are untouched; only the write path is
+// wrapped here.
+type batchingConn struct {
+	net.Conn
+
+	mu      sync.Mutex
+	holding bool
+	buf     []byte
+}
+
+func newBatchingConn(conn net.Conn) *batchingConn {
+	return &batchingConn{Conn: conn}
+}
+
+// Write buffers p instead of writing it through while holding is on;
+// otherwise it's a plain passthrough to the underlying connection.
+func (b *batchingConn) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	if !b.holding {
+		b.mu.Unlock()
+		return b.Conn.Write(p)
+	}
+	b.buf = append(b.buf, p...)
+	b.mu.Unlock()
+	return len(p), nil
+}
+
+// hold starts buffering writes in memory instead of passing them
+// through, until release is called.
+func (b *batchingConn) hold() {
+	b.mu.Lock()
+	b.holding = true
+	b.mu.Unlock()
+}
+
+// release pushes everything buffered since hold to the real connection
+// in one Write call and goes back to passing writes straight through.
+func (b *batchingConn) release() error {
+	b.mu.Lock()
+	buf := b.buf
+	b.buf = nil
+	b.holding = false
+	b.mu.Unlock()
+
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := b.Conn.Write(buf)
+	return err
+}
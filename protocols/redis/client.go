@@ -0,0 +1,26 @@
+package redisProtocol
+
+import (
+	"bufio"
+	"net"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// respBufferSize is the size of the read buffer used to parse the RESP
+// stream for each connection, independent of whatever buffering
+// server.NetworkClient does internally for writes.
+const respBufferSize = 4096
+
+// NewRedisProtocolClientSize creates the network client for a single
+// redis protocol connection and primes the RESP reader used by
+// RedisProtocol.RunClient to pull pipelined commands off the wire.
+func NewRedisProtocolClientSize(conn net.Conn, size int) (server.ProtocolClient, error) {
+	batch := newBatchingConn(conn)
+	client := server.NewNetworkClient(batch, size)
+	state := stateFor(client)
+	state.reader = bufio.NewReaderSize(conn, respBufferSize)
+	state.conn = batch
+	state.batch = batch
+	return client, nil
+}
@@ -0,0 +1,40 @@
+package redisProtocol
+
+import (
+	"errors"
+
+	"github.com/nyxtom/broadcast/pubsub"
+	"github.com/nyxtom/broadcast/server"
+)
+
+var errSubscribeModeOnly = errors.New("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+
+// pubsubCommands may still be run once a connection is in subscribe
+// mode, matching what real redis allows.
+var pubsubCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"PING":         true,
+}
+
+// SetPubSub wires up the pub/sub backend RunClient consults to reject
+// non-pubsub commands from a subscribed connection and to tear down its
+// subscriptions on disconnect.
+func (p *RedisProtocol) SetPubSub(backend *pubsub.PubSubBackend) {
+	p.pubsub = backend
+}
+
+// checkSubscribeMode enforces that a client with active subscriptions
+// only sends pubsub commands (plus the QUIT/HELLO/AUTH handled earlier
+// in handleData).
+func (p *RedisProtocol) checkSubscribeMode(cmd string, client server.ProtocolClient) error {
+	if p.pubsub == nil || !p.pubsub.IsSubscribed(client) {
+		return nil
+	}
+	if !pubsubCommands[cmd] {
+		return errSubscribeModeOnly
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/nyxtom/broadcast/pubsub"
 	"github.com/nyxtom/broadcast/server"
 )
 
@@ -15,7 +16,10 @@ var errCmdNotFound = errors.New("invalid command format")
 var errQuit = errors.New("client quit")
 
 type RedisProtocol struct {
-	ctx *server.BroadcastContext
+	ctx     *server.BroadcastContext
+	auth    *AuthConfig
+	pubsub  *pubsub.PubSubBackend
+	watcher Watcher
 }
 
 func NewRedisProtocol() *RedisProtocol {
@@ -41,24 +45,45 @@ func (p *RedisProtocol) RunClient(client server.ProtocolClient) {
 			p.ctx.Events <- server.BroadcastEvent{"fatal", "client run panic", errors.New(fmt.Sprintf("%v", e)), buf}
 		}
 
+		if p.pubsub != nil {
+			p.pubsub.Disconnect(client)
+		}
+		state := stateFor(client)
+		state.batch.release()
+		clearState(client)
 		client.Close()
 		return
 	}()
 
+	state := stateFor(client)
+	reader := state.reader
 	for {
-		data, err := client.ReadBulkPayload()
+		data, err := readCommand(reader)
 		if err != nil {
+			state.batch.release()
 			if err != io.EOF {
 				p.ctx.Events <- server.BroadcastEvent{"error", "read error", err, nil}
 			}
 			return
 		}
 
+		// An inline blank line or a `*0\r\n` empty array is a no-op; real
+		// clients send these as keep-alives rather than commands.
+		if len(data) == 0 {
+			continue
+		}
+
+		// Hold this command's reply (and any more that follow it while
+		// already pipelined) in memory rather than writing it straight
+		// through; see the release below and batch.go.
+		state.batch.hold()
+
 		err = p.handleData(data, client)
 		if err != nil {
 			if err == errQuit {
 				client.WriteString("OK")
 				client.Flush()
+				state.batch.release()
 				return
 			} else {
 				p.ctx.Events <- server.BroadcastEvent{"error", "accept error", err, nil}
@@ -66,20 +91,66 @@ func (p *RedisProtocol) RunClient(client server.ProtocolClient) {
 				client.Flush()
 			}
 		}
+
+		// Every reply so far is still only sitting in the batching
+		// conn's in-memory buffer (see batch.go). Only push it to the
+		// socket once the reader has no more already-pipelined commands
+		// waiting, so a client that sent many commands in one write
+		// gets them answered with one write back instead of one per
+		// command.
+		if reader.Buffered() == 0 {
+			if err := state.batch.release(); err != nil {
+				p.ctx.Events <- server.BroadcastEvent{"error", "write error", err, nil}
+				return
+			}
+		}
 	}
 }
 
 func (p *RedisProtocol) handleData(data [][]byte, client server.ProtocolClient) error {
 	cmd := strings.ToUpper(string(data[0]))
-	switch {
-	case cmd == "QUIT":
+
+	state := stateFor(client)
+	if state.inMulti && cmd != "QUIT" && !transactionCommands[cmd] {
+		state.queue = append(state.queue, data)
+		client.WriteString("QUEUED")
+		client.Flush()
+		return nil
+	}
+
+	switch cmd {
+	case "QUIT":
 		return errQuit
-	default:
-		handler, ok := p.ctx.Commands[cmd]
-		if !ok {
-			return errCmdNotFound
-		}
+	case "HELLO":
+		return p.handleHello(data[1:], client)
+	case "AUTH":
+		return p.handleAuth(data[1:], client)
+	case "PING":
+		return p.handlePing(data[1:], client)
+	}
+
+	if err := p.authorize(cmd, state); err != nil {
+		return err
+	}
+	if err := p.checkSubscribeMode(cmd, client); err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "MULTI":
+		return p.handleMulti(client)
+	case "DISCARD":
+		return p.handleDiscard(client)
+	case "WATCH":
+		return p.handleWatch(data[1:], client)
+	case "EXEC":
+		return p.handleExec(client)
+	}
 
-		return handler(data[1:], client)
+	handler, ok := p.ctx.Commands[cmd]
+	if !ok {
+		return errCmdNotFound
 	}
-}
\ No newline at end of file
+
+	return handler(data[1:], client)
+}
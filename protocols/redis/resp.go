@@ -0,0 +1,154 @@
+package redisProtocol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// RESP type prefixes used while parsing commands off the wire.
+const (
+	typeSimpleString = '+'
+	typeError        = '-'
+	typeInteger      = ':'
+	typeBulkString   = '$'
+	typeArray        = '*'
+)
+
+var (
+	errProtocol    = errors.New("Protocol error: invalid multibulk length")
+	errInvalidBulk = errors.New("Protocol error: invalid bulk length")
+)
+
+// Sane ceilings on the counts/lengths a command header can declare before
+// we allocate for them, mirroring real redis's multibulk and
+// proto-max-bulk-len limits. Without these, a single `*999999999\r\n` or
+// `$999999999999\r\n` header lets a peer make us allocate gigabytes before
+// we've even read a byte of the body.
+const (
+	maxMultiBulkLen = 1024 * 1024
+	maxBulkLen      = 512 * 1024 * 1024
+)
+
+// readCommand reads a single RESP2 command from r. It understands both
+// the inline command form (a bare line of space separated arguments, the
+// way `nc` or a human telnetting in would send one) and the multi-bulk
+// array form (`*N\r\n$len\r\narg\r\n...`) that every real client library
+// speaks. A null bulk string (`$-1\r\n`) comes back as a nil element and
+// an empty array (`*0\r\n`) as a zero length command, which callers treat
+// as a no-op rather than a malformed request.
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) == 0 {
+		return [][]byte{}, nil
+	}
+
+	if line[0] != typeArray {
+		return parseInline(line), nil
+	}
+
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil {
+		return nil, errProtocol
+	}
+	if count <= 0 {
+		return [][]byte{}, nil
+	}
+	if count > maxMultiBulkLen {
+		return nil, errProtocol
+	}
+
+	args := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		bulk, err := readBulkArg(r)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, bulk)
+	}
+	return args, nil
+}
+
+// readBulkArg reads a single `$len\r\n...\r\n` bulk string argument that
+// makes up one element of a multi-bulk command.
+func readBulkArg(r *bufio.Reader) ([]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != typeBulkString {
+		return nil, errInvalidBulk
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil {
+		return nil, errInvalidBulk
+	}
+	if n < 0 {
+		return nil, nil
+	}
+	if n > maxBulkLen {
+		return nil, errInvalidBulk
+	}
+
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+func parseInline(line []byte) [][]byte {
+	return bytes.Fields(line)
+}
+
+var errNoProto = errors.New("NOPROTO unsupported protocol version")
+
+// handleHello implements the redis 6+ `HELLO [protover]` handshake. It
+// lets a client opt into RESP3 without erroring out, but we don't speak
+// any RESP3-only wire forms yet: the negotiated version is recorded on
+// the connection's state (state.resp3) purely for future use, and every
+// reply, including this one, is still written in RESP2 form regardless
+// of what was negotiated.
+func (p *RedisProtocol) handleHello(args [][]byte, client server.ProtocolClient) error {
+	state := stateFor(client)
+	proto := 2
+	if len(args) > 0 {
+		v, err := strconv.Atoi(string(args[0]))
+		if err != nil || (v != 2 && v != 3) {
+			return errNoProto
+		}
+		proto = v
+	}
+	state.resp3 = proto == 3
+
+	// A flat key/value array rather than a RESP3 map: the client's
+	// writer has no map primitive, and a real client's HELLO parser
+	// accepts the RESP2 array form regardless of the protover it asked
+	// for.
+	client.WriteArray([]interface{}{
+		"server", "broadcast",
+		"version", "1.0",
+		"proto", int64(proto),
+		"mode", "standalone",
+		"role", "master",
+	})
+	client.Flush()
+	return nil
+}
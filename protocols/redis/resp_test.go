@@ -0,0 +1,114 @@
+package redisProtocol
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestReadCommandInline(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PING hello\r\n"))
+	data, err := readCommand(r)
+	if err != nil {
+		t.Fatalf("readCommand: %v", err)
+	}
+	want := []string{"PING", "hello"}
+	if len(data) != len(want) {
+		t.Fatalf("got %d args, want %d", len(data), len(want))
+	}
+	for i, w := range want {
+		if string(data[i]) != w {
+			t.Errorf("arg %d = %q, want %q", i, data[i], w)
+		}
+	}
+}
+
+func TestReadCommandMultiBulk(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n"))
+	data, err := readCommand(r)
+	if err != nil {
+		t.Fatalf("readCommand: %v", err)
+	}
+	want := []string{"GET", "foo"}
+	if len(data) != len(want) {
+		t.Fatalf("got %d args, want %d", len(data), len(want))
+	}
+	for i, w := range want {
+		if string(data[i]) != w {
+			t.Errorf("arg %d = %q, want %q", i, data[i], w)
+		}
+	}
+}
+
+func TestReadCommandEmptyArray(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*0\r\n"))
+	data, err := readCommand(r)
+	if err != nil {
+		t.Fatalf("readCommand: %v", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("got %d args, want 0", len(data))
+	}
+}
+
+func TestReadCommandBlankLine(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\r\n"))
+	data, err := readCommand(r)
+	if err != nil {
+		t.Fatalf("readCommand: %v", err)
+	}
+	if len(data) != 0 {
+		t.Fatalf("got %d args, want 0", len(data))
+	}
+}
+
+func TestReadCommandPipelined(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PING\r\nPING\r\n"))
+
+	first, err := readCommand(r)
+	if err != nil {
+		t.Fatalf("readCommand (first): %v", err)
+	}
+	if len(first) != 1 || string(first[0]) != "PING" {
+		t.Fatalf("first command = %v, want [PING]", first)
+	}
+	if r.Buffered() == 0 {
+		t.Fatalf("expected the second pipelined command to still be buffered")
+	}
+
+	second, err := readCommand(r)
+	if err != nil {
+		t.Fatalf("readCommand (second): %v", err)
+	}
+	if len(second) != 1 || string(second[0]) != "PING" {
+		t.Fatalf("second command = %v, want [PING]", second)
+	}
+}
+
+func TestReadCommandMultiBulkCountTooLarge(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*999999999\r\n"))
+	if _, err := readCommand(r); err != errProtocol {
+		t.Fatalf("readCommand = %v, want errProtocol", err)
+	}
+}
+
+func TestReadCommandBulkLengthTooLarge(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*1\r\n$999999999999\r\n"))
+	if _, err := readCommand(r); err != errInvalidBulk {
+		t.Fatalf("readCommand = %v, want errInvalidBulk", err)
+	}
+}
+
+func TestReadCommandNullBulk(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$-1\r\n"))
+	data, err := readCommand(r)
+	if err != nil {
+		t.Fatalf("readCommand: %v", err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("got %d args, want 2", len(data))
+	}
+	if data[1] != nil {
+		t.Errorf("arg 1 = %q, want nil", data[1])
+	}
+}
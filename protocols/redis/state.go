@@ -0,0 +1,61 @@
+package redisProtocol
+
+import (
+	"bufio"
+	"net"
+	"sync"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// connState tracks the per-connection protocol state that the shared
+// server.NetworkClient has no notion of: the RESP version it has
+// negotiated, its auth state and its MULTI/WATCH transaction state.
+// Subscribe mode is tracked by the pubsub backend, not here. It is keyed by the client's NetworkClient pointer and
+// dropped once the connection's RunClient loop returns.
+type connState struct {
+	reader *bufio.Reader
+	resp3  bool
+
+	// conn is the connection underlying the client's NetworkClient (a
+	// *batchingConn, see batch.go). handleExec writes the EXEC array
+	// header directly to it, ahead of the queued commands' own replies,
+	// since NetworkClient has no primitive for composing several writes
+	// into one RESP array.
+	conn net.Conn
+
+	// batch is the same connection as conn, kept typed so RunClient can
+	// call release() on it once a run of pipelined commands is drained,
+	// instead of after every single one.
+	batch *batchingConn
+
+	authenticated bool
+	user          string
+
+	inMulti bool
+	queue   [][][]byte
+	watched map[string]int64
+}
+
+var (
+	statesMu sync.Mutex
+	states   = make(map[*server.NetworkClient]*connState)
+)
+
+func stateFor(client *server.NetworkClient) *connState {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+
+	s, ok := states[client]
+	if !ok {
+		s = new(connState)
+		states[client] = s
+	}
+	return s
+}
+
+func clearState(client *server.NetworkClient) {
+	statesMu.Lock()
+	delete(states, client)
+	statesMu.Unlock()
+}
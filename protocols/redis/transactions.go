@@ -0,0 +1,149 @@
+package redisProtocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// execMu serializes EXEC blocks across connections so a transaction's
+// queued commands run back-to-back without another connection's EXEC
+// interleaving with them. It is scoped to EXEC alone, not to every
+// command dispatch: handleData (called for a connection's ordinary,
+// non-transactional commands by RunClient) never takes it, both because
+// that would serialize the whole server's command throughput on one
+// global critical section, and because handleExec reuses handleData to
+// replay its queue — were handleData to also take execMu, an EXEC would
+// deadlock retaking the lock it already holds.
+var execMu sync.Mutex
+
+// transactionCommands are handled directly by handleData even while a
+// connection is queuing; every other command gets queued instead of
+// run once MULTI is active.
+var transactionCommands = map[string]bool{
+	"MULTI":   true,
+	"EXEC":    true,
+	"DISCARD": true,
+	"WATCH":   true,
+}
+
+// Watcher resolves the current version of a key, satisfied by
+// stats.StatsBackend. WATCH/EXEC use it to detect a key changing
+// between the WATCH and the EXEC that follows it.
+type Watcher interface {
+	Version(key string) (int64, error)
+}
+
+// SetWatcher wires up the backend WATCH consults for per-key versions.
+// Without one, WATCH is accepted but never aborts an EXEC.
+func (p *RedisProtocol) SetWatcher(w Watcher) {
+	p.watcher = w
+}
+
+func (p *RedisProtocol) handleMulti(client server.ProtocolClient) error {
+	state := stateFor(client)
+	if state.inMulti {
+		return errors.New("ERR MULTI calls can not be nested")
+	}
+	state.inMulti = true
+	state.queue = nil
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+func (p *RedisProtocol) handleDiscard(client server.ProtocolClient) error {
+	state := stateFor(client)
+	if !state.inMulti {
+		return errors.New("ERR DISCARD without MULTI")
+	}
+	state.inMulti = false
+	state.queue = nil
+	state.watched = nil
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+func (p *RedisProtocol) handleWatch(args [][]byte, client server.ProtocolClient) error {
+	state := stateFor(client)
+	if state.inMulti {
+		return errors.New("ERR WATCH inside MULTI is not allowed")
+	}
+	if len(args) == 0 {
+		return errors.New("ERR wrong number of arguments for 'watch' command")
+	}
+
+	if state.watched == nil {
+		state.watched = make(map[string]int64)
+	}
+	for _, k := range args {
+		key := string(k)
+		var version int64
+		if p.watcher != nil {
+			version, _ = p.watcher.Version(key)
+		}
+		state.watched[key] = version
+	}
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// handleExec replays a connection's queued commands. server.NetworkClient
+// has no primitive for composing several writes into one RESP array, so
+// each queued command still ends up written (and flushed) individually
+// by its own handler; instead we write the `*N\r\n` array header
+// straight to the connection ourselves, ahead of them, so the bytes on
+// the wire form one well-formed array reply rather than N bare ones. An
+// empty queue still needs a reply (`*0\r\n`), or a client that ran
+// `MULTI; EXEC` with nothing queued hangs waiting for one.
+func (p *RedisProtocol) handleExec(client server.ProtocolClient) error {
+	state := stateFor(client)
+	if !state.inMulti {
+		return errors.New("ERR EXEC without MULTI")
+	}
+
+	queue := state.queue
+	watched := state.watched
+	state.inMulti = false
+	state.queue = nil
+	state.watched = nil
+
+	execMu.Lock()
+	defer execMu.Unlock()
+
+	if p.watcherDirty(watched) {
+		// A real client's EXEC reply parser expects a RESP null array
+		// here, not the internal WriteJson envelope.
+		client.WriteNull()
+		client.Flush()
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(state.conn, "*%d\r\n", len(queue)); err != nil {
+		return err
+	}
+	for _, cmd := range queue {
+		if err := p.handleData(cmd, client); err != nil {
+			client.WriteError(err)
+			client.Flush()
+		}
+	}
+	return nil
+}
+
+func (p *RedisProtocol) watcherDirty(watched map[string]int64) bool {
+	if p.watcher == nil || len(watched) == 0 {
+		return false
+	}
+	for key, version := range watched {
+		current, _ := p.watcher.Version(key)
+		if current != version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package redisProtocol
+
+import "testing"
+
+type fakeWatcher map[string]int64
+
+func (f fakeWatcher) Version(key string) (int64, error) {
+	return f[key], nil
+}
+
+func TestWatcherDirtyNoWatcherConfigured(t *testing.T) {
+	p := &RedisProtocol{}
+	if p.watcherDirty(map[string]int64{"foo": 1}) {
+		t.Error("watcherDirty with no watcher configured = true, want false")
+	}
+}
+
+func TestWatcherDirtyNothingWatched(t *testing.T) {
+	p := &RedisProtocol{}
+	p.SetWatcher(fakeWatcher{"foo": 5})
+	if p.watcherDirty(nil) {
+		t.Error("watcherDirty with nothing watched = true, want false")
+	}
+}
+
+func TestWatcherDirtyUnchangedVersions(t *testing.T) {
+	p := &RedisProtocol{}
+	p.SetWatcher(fakeWatcher{"foo": 1, "bar": 2})
+	watched := map[string]int64{"foo": 1, "bar": 2}
+	if p.watcherDirty(watched) {
+		t.Error("watcherDirty with unchanged versions = true, want false")
+	}
+}
+
+func TestWatcherDirtyChangedVersion(t *testing.T) {
+	p := &RedisProtocol{}
+	p.SetWatcher(fakeWatcher{"foo": 2})
+	watched := map[string]int64{"foo": 1}
+	if !p.watcherDirty(watched) {
+		t.Error("watcherDirty with a bumped version = false, want true")
+	}
+}
@@ -0,0 +1,266 @@
+package pubsub
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// subscriberBufferSize bounds how many unread messages a slow
+// subscriber can accumulate before the broker's drop policy kicks in.
+const subscriberBufferSize = 256
+
+// Config controls how RegisterBackend wires up the broker. It's a
+// package-level var, set before RegisterBackend runs, mirroring how
+// stats.PersistenceConfig configures that backend.
+var Config = struct {
+	// DropPolicy selects what happens to a slow subscriber once its
+	// mailbox fills up; see DropNewest/DropOldest.
+	DropPolicy DropPolicy
+}{
+	DropPolicy: DropOldest,
+}
+
+// PubSubBackend exposes SUBSCRIBE/UNSUBSCRIBE/PSUBSCRIBE/PUNSUBSCRIBE
+// and PUBLISH as ordinary registered commands, backed by a single
+// Broker shared across every connection.
+type PubSubBackend struct {
+	server.Backend
+
+	broker *Broker
+
+	mu          sync.Mutex
+	subscribers map[*server.NetworkClient]*Subscriber
+	writeLocks  map[*server.NetworkClient]*sync.Mutex
+}
+
+func RegisterBackend(app *server.BroadcastServer) (server.Backend, error) {
+	backend := &PubSubBackend{
+		broker:      NewBroker(subscriberBufferSize, Config.DropPolicy),
+		subscribers: make(map[*server.NetworkClient]*Subscriber),
+		writeLocks:  make(map[*server.NetworkClient]*sync.Mutex),
+	}
+
+	commandHelp := []server.Command{
+		server.Command{"SUBSCRIBE", "Subscribes the client to one or more channels.", "SUBSCRIBE channel [channel ...]"},
+		server.Command{"UNSUBSCRIBE", "Unsubscribes the client from one or more channels, or all of them if none are given.", "UNSUBSCRIBE [channel ...]"},
+		server.Command{"PSUBSCRIBE", "Subscribes the client to one or more glob patterns.", "PSUBSCRIBE pattern [pattern ...]"},
+		server.Command{"PUNSUBSCRIBE", "Unsubscribes the client from one or more glob patterns, or all of them if none are given.", "PUNSUBSCRIBE [pattern ...]"},
+		server.Command{"PUBLISH", "Publishes a message to a channel, returning the number of subscribers it was delivered to.", "PUBLISH channel message"},
+	}
+	commands := []server.Handler{
+		backend.Subscribe,
+		backend.Unsubscribe,
+		backend.PSubscribe,
+		backend.PUnsubscribe,
+		backend.Publish,
+	}
+
+	for i := range commandHelp {
+		app.RegisterCommand(commandHelp[i], commands[i])
+	}
+
+	return backend, nil
+}
+
+func (backend *PubSubBackend) Load() error {
+	return nil
+}
+
+func (backend *PubSubBackend) Unload() error {
+	return nil
+}
+
+// subscriberFor returns the Subscriber for client, creating it (and
+// starting its delivery pump) on first use.
+func (backend *PubSubBackend) subscriberFor(client *server.NetworkClient) *Subscriber {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	sub, ok := backend.subscribers[client]
+	if ok {
+		return sub
+	}
+
+	sub = backend.broker.NewSubscriber()
+	backend.subscribers[client] = sub
+	backend.writeLocks[client] = new(sync.Mutex)
+	go backend.pump(client, sub)
+	return sub
+}
+
+// pump forwards published messages to client until its mailbox is
+// closed by Disconnect. It shares writeLock with the command handlers
+// below so an async pubsub message never interleaves with a reply to a
+// (P)(UN)SUBSCRIBE the client just sent.
+func (backend *PubSubBackend) pump(client *server.NetworkClient, sub *Subscriber) {
+	for msg := range sub.Messages() {
+		lock := backend.writeLock(client)
+		if lock == nil {
+			// Disconnect has already dropped this client.
+			return
+		}
+		lock.Lock()
+		if msg.Pattern != "" {
+			client.WriteBulk([][]byte{[]byte("pmessage"), []byte(msg.Pattern), []byte(msg.Channel), []byte(msg.Payload)})
+		} else {
+			client.WriteBulk([][]byte{[]byte("message"), []byte(msg.Channel), []byte(msg.Payload)})
+		}
+		client.Flush()
+		lock.Unlock()
+	}
+}
+
+func (backend *PubSubBackend) writeLock(client *server.NetworkClient) *sync.Mutex {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.writeLocks[client]
+}
+
+// IsSubscribed reports whether client currently has any active
+// channel/pattern subscriptions; RedisProtocol.RunClient uses this to
+// reject non-pubsub commands while a connection is in subscribe mode.
+func (backend *PubSubBackend) IsSubscribed(client *server.NetworkClient) bool {
+	backend.mu.Lock()
+	sub, ok := backend.subscribers[client]
+	backend.mu.Unlock()
+	return ok && sub.Count() > 0
+}
+
+// Disconnect tears down everything a connection subscribed to. It must
+// be called once when a client goes away (RedisProtocol.RunClient's
+// defer), or its pump goroutine and broker registrations leak forever.
+func (backend *PubSubBackend) Disconnect(client *server.NetworkClient) {
+	backend.mu.Lock()
+	sub, ok := backend.subscribers[client]
+	delete(backend.subscribers, client)
+	delete(backend.writeLocks, client)
+	backend.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	backend.broker.UnsubscribeAll(sub)
+	close(sub.messages)
+}
+
+func (backend *PubSubBackend) Subscribe(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) == 0 {
+		client.WriteError(errors.New("SUBSCRIBE takes at least 1 parameter (i.e. channel to subscribe to)"))
+		client.Flush()
+		return nil
+	}
+
+	sub := backend.subscriberFor(client)
+	backend.writeLock(client).Lock()
+	defer backend.writeLock(client).Unlock()
+
+	for _, c := range d {
+		channel := string(c)
+		backend.broker.Subscribe(sub, channel)
+		client.WriteArray([]interface{}{"subscribe", channel, sub.Count()})
+	}
+	client.Flush()
+	return nil
+}
+
+func (backend *PubSubBackend) Unsubscribe(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	sub := backend.subscriberFor(client)
+	backend.writeLock(client).Lock()
+	defer backend.writeLock(client).Unlock()
+
+	channels := make([]string, 0, len(d))
+	for _, c := range d {
+		channels = append(channels, string(c))
+	}
+	if len(channels) == 0 {
+		sub.mu.Lock()
+		for c := range sub.channels {
+			channels = append(channels, c)
+		}
+		sub.mu.Unlock()
+	}
+
+	if len(channels) == 0 {
+		// A client that isn't subscribed to anything still gets one
+		// reply with a nil channel, matching real redis; otherwise a
+		// defensive UNSUBSCRIBE during teardown hangs waiting for one.
+		client.WriteArray([]interface{}{"unsubscribe", nil, sub.Count()})
+	}
+	for _, channel := range channels {
+		backend.broker.Unsubscribe(sub, channel)
+		client.WriteArray([]interface{}{"unsubscribe", channel, sub.Count()})
+	}
+	client.Flush()
+	return nil
+}
+
+func (backend *PubSubBackend) PSubscribe(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) == 0 {
+		client.WriteError(errors.New("PSUBSCRIBE takes at least 1 parameter (i.e. pattern to subscribe to)"))
+		client.Flush()
+		return nil
+	}
+
+	sub := backend.subscriberFor(client)
+	backend.writeLock(client).Lock()
+	defer backend.writeLock(client).Unlock()
+
+	for _, p := range d {
+		pattern := string(p)
+		backend.broker.PSubscribe(sub, pattern)
+		client.WriteArray([]interface{}{"psubscribe", pattern, sub.Count()})
+	}
+	client.Flush()
+	return nil
+}
+
+func (backend *PubSubBackend) PUnsubscribe(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	sub := backend.subscriberFor(client)
+	backend.writeLock(client).Lock()
+	defer backend.writeLock(client).Unlock()
+
+	patterns := make([]string, 0, len(d))
+	for _, p := range d {
+		patterns = append(patterns, string(p))
+	}
+	if len(patterns) == 0 {
+		sub.mu.Lock()
+		for p := range sub.patterns {
+			patterns = append(patterns, p)
+		}
+		sub.mu.Unlock()
+	}
+
+	if len(patterns) == 0 {
+		// Same as Unsubscribe: always send one reply, even when there's
+		// nothing to unsubscribe from.
+		client.WriteArray([]interface{}{"punsubscribe", nil, sub.Count()})
+	}
+	for _, pattern := range patterns {
+		backend.broker.PUnsubscribe(sub, pattern)
+		client.WriteArray([]interface{}{"punsubscribe", pattern, sub.Count()})
+	}
+	client.Flush()
+	return nil
+}
+
+func (backend *PubSubBackend) Publish(data interface{}, client *server.NetworkClient) error {
+	d, _ := data.([][]byte)
+	if len(d) < 2 {
+		client.WriteError(errors.New("PUBLISH takes 2 parameters (i.e. channel and message)"))
+		client.Flush()
+		return nil
+	}
+
+	channel := string(d[0])
+	payload := string(d[1])
+	client.WriteInt64(int64(backend.broker.Publish(channel, payload)))
+	client.Flush()
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package pubsub implements a redis-compatible publish/subscribe
+// broker: SUBSCRIBE/PSUBSCRIBE register a client's interest in channels
+// or glob patterns, and PUBLISH fans a message out to every matching
+// subscriber.
+package pubsub
+
+import "sync"
+
+// DropPolicy controls what happens when a subscriber's outbound buffer
+// is full and another message arrives for it.
+type DropPolicy int
+
+const (
+	// DropNewest discards the incoming message, leaving a slow
+	// subscriber's queue as-is.
+	DropNewest DropPolicy = iota
+	// DropOldest evicts the oldest queued message to make room for the
+	// new one, favoring freshness over completeness.
+	DropOldest
+)
+
+// Message is a single published event. Pattern is empty unless the
+// subscriber matched it through PSUBSCRIBE.
+type Message struct {
+	Channel string
+	Pattern string
+	Payload string
+}
+
+// Subscriber is one client's mailbox plus the channels and patterns it
+// is currently listening on.
+type Subscriber struct {
+	messages chan Message
+
+	mu       sync.Mutex
+	channels map[string]bool
+	patterns map[string]bool
+}
+
+func newSubscriber(bufferSize int) *Subscriber {
+	return &Subscriber{
+		messages: make(chan Message, bufferSize),
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+	}
+}
+
+// Messages is the channel a subscriber's pump reads from to forward
+// published messages out to the client.
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Count returns the number of channels and patterns this subscriber is
+// currently listening on, used for the SUBSCRIBE/UNSUBSCRIBE reply
+// count redis clients expect.
+func (s *Subscriber) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.channels) + len(s.patterns)
+}
+
+// Broker fans published messages out to subscribers of matching
+// channels and patterns.
+type Broker struct {
+	mu         sync.RWMutex
+	channels   map[string]map[*Subscriber]bool
+	patterns   map[string]map[*Subscriber]bool
+	bufferSize int
+	dropPolicy DropPolicy
+}
+
+// NewBroker creates a broker whose subscribers each get a mailbox of
+// bufferSize messages; once full, dropPolicy decides which message is
+// lost rather than blocking PUBLISH on a slow consumer.
+func NewBroker(bufferSize int, dropPolicy DropPolicy) *Broker {
+	return &Broker{
+		channels:   make(map[string]map[*Subscriber]bool),
+		patterns:   make(map[string]map[*Subscriber]bool),
+		bufferSize: bufferSize,
+		dropPolicy: dropPolicy,
+	}
+}
+
+// NewSubscriber creates a mailbox sized for this broker. The caller owns
+// registering and tearing it down via Subscribe/UnsubscribeAll.
+func (b *Broker) NewSubscriber() *Subscriber {
+	return newSubscriber(b.bufferSize)
+}
+
+func (b *Broker) Subscribe(sub *Subscriber, channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	set, ok := b.channels[channel]
+	if !ok {
+		set = make(map[*Subscriber]bool)
+		b.channels[channel] = set
+	}
+	set[sub] = true
+
+	sub.mu.Lock()
+	sub.channels[channel] = true
+	sub.mu.Unlock()
+}
+
+func (b *Broker) Unsubscribe(sub *Subscriber, channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if set, ok := b.channels[channel]; ok {
+		delete(set, sub)
+		if len(set) == 0 {
+			delete(b.channels, channel)
+		}
+	}
+
+	sub.mu.Lock()
+	delete(sub.channels, channel)
+	sub.mu.Unlock()
+}
+
+func (b *Broker) PSubscribe(sub *Subscriber, pattern string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	set, ok := b.patterns[pattern]
+	if !ok {
+		set = make(map[*Subscriber]bool)
+		b.patterns[pattern] = set
+	}
+	set[sub] = true
+
+	sub.mu.Lock()
+	sub.patterns[pattern] = true
+	sub.mu.Unlock()
+}
+
+func (b *Broker) PUnsubscribe(sub *Subscriber, pattern string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if set, ok := b.patterns[pattern]; ok {
+		delete(set, sub)
+		if len(set) == 0 {
+			delete(b.patterns, pattern)
+		}
+	}
+
+	sub.mu.Lock()
+	delete(sub.patterns, pattern)
+	sub.mu.Unlock()
+}
+
+// UnsubscribeAll removes sub from every channel and pattern it was
+// listening on; called once a connection drops.
+func (b *Broker) UnsubscribeAll(sub *Subscriber) {
+	sub.mu.Lock()
+	channels := make([]string, 0, len(sub.channels))
+	for c := range sub.channels {
+		channels = append(channels, c)
+	}
+	patterns := make([]string, 0, len(sub.patterns))
+	for p := range sub.patterns {
+		patterns = append(patterns, p)
+	}
+	sub.mu.Unlock()
+
+	for _, c := range channels {
+		b.Unsubscribe(sub, c)
+	}
+	for _, p := range patterns {
+		b.PUnsubscribe(sub, p)
+	}
+}
+
+// Publish delivers payload to every direct subscriber of channel and
+// every subscriber whose pattern glob-matches it, returning the number
+// of subscribers it was handed off to (a slow consumer that got dropped
+// per dropPolicy doesn't count).
+func (b *Broker) Publish(channel, payload string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	delivered := 0
+	for sub := range b.channels[channel] {
+		if b.deliver(sub, Message{Channel: channel, Payload: payload}) {
+			delivered++
+		}
+	}
+	for pattern, subs := range b.patterns {
+		if !globMatch(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			if b.deliver(sub, Message{Channel: channel, Pattern: pattern, Payload: payload}) {
+				delivered++
+			}
+		}
+	}
+	return delivered
+}
+
+func (b *Broker) deliver(sub *Subscriber, msg Message) bool {
+	select {
+	case sub.messages <- msg:
+		return true
+	default:
+	}
+
+	if b.dropPolicy != DropOldest {
+		return false
+	}
+
+	select {
+	case <-sub.messages:
+	default:
+	}
+	select {
+	case sub.messages <- msg:
+		return true
+	default:
+		return false
+	}
+}
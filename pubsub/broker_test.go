@@ -0,0 +1,99 @@
+package pubsub
+
+import "testing"
+
+func TestPublishDeliversToChannelAndPatternSubscribers(t *testing.T) {
+	b := NewBroker(4, DropNewest)
+
+	direct := b.NewSubscriber()
+	b.Subscribe(direct, "news.tech")
+
+	patterned := b.NewSubscriber()
+	b.PSubscribe(patterned, "news.*")
+
+	n := b.Publish("news.tech", "hello")
+	if n != 2 {
+		t.Fatalf("Publish delivered to %d subscribers, want 2", n)
+	}
+
+	msg := <-direct.Messages()
+	if msg.Channel != "news.tech" || msg.Pattern != "" || msg.Payload != "hello" {
+		t.Errorf("direct subscriber got %+v, want channel-only match", msg)
+	}
+
+	msg = <-patterned.Messages()
+	if msg.Channel != "news.tech" || msg.Pattern != "news.*" || msg.Payload != "hello" {
+		t.Errorf("patterned subscriber got %+v, want pattern match on news.*", msg)
+	}
+}
+
+func TestPublishToUnmatchedChannelDeliversNothing(t *testing.T) {
+	b := NewBroker(4, DropNewest)
+	sub := b.NewSubscriber()
+	b.Subscribe(sub, "news.tech")
+
+	if n := b.Publish("news.weather", "hello"); n != 0 {
+		t.Errorf("Publish to unsubscribed channel delivered to %d, want 0", n)
+	}
+}
+
+func TestUnsubscribeStopsDelivery(t *testing.T) {
+	b := NewBroker(4, DropNewest)
+	sub := b.NewSubscriber()
+	b.Subscribe(sub, "news.tech")
+	b.Unsubscribe(sub, "news.tech")
+
+	if n := b.Publish("news.tech", "hello"); n != 0 {
+		t.Errorf("Publish after Unsubscribe delivered to %d, want 0", n)
+	}
+}
+
+func TestUnsubscribeAllClearsChannelsAndPatterns(t *testing.T) {
+	b := NewBroker(4, DropNewest)
+	sub := b.NewSubscriber()
+	b.Subscribe(sub, "news.tech")
+	b.PSubscribe(sub, "news.*")
+
+	b.UnsubscribeAll(sub)
+
+	if sub.Count() != 0 {
+		t.Errorf("Count after UnsubscribeAll = %d, want 0", sub.Count())
+	}
+	if n := b.Publish("news.tech", "hello"); n != 0 {
+		t.Errorf("Publish after UnsubscribeAll delivered to %d, want 0", n)
+	}
+}
+
+func TestDropNewestDiscardsMessageWhenMailboxFull(t *testing.T) {
+	b := NewBroker(1, DropNewest)
+	sub := b.NewSubscriber()
+	b.Subscribe(sub, "news.tech")
+
+	b.Publish("news.tech", "first")
+	n := b.Publish("news.tech", "second")
+	if n != 0 {
+		t.Errorf("Publish into a full mailbox under DropNewest delivered %d, want 0", n)
+	}
+
+	msg := <-sub.Messages()
+	if msg.Payload != "first" {
+		t.Errorf("mailbox held %q, want the original message preserved", msg.Payload)
+	}
+}
+
+func TestDropOldestEvictsToMakeRoomForNewest(t *testing.T) {
+	b := NewBroker(1, DropOldest)
+	sub := b.NewSubscriber()
+	b.Subscribe(sub, "news.tech")
+
+	b.Publish("news.tech", "first")
+	n := b.Publish("news.tech", "second")
+	if n != 1 {
+		t.Errorf("Publish into a full mailbox under DropOldest delivered %d, want 1", n)
+	}
+
+	msg := <-sub.Messages()
+	if msg.Payload != "second" {
+		t.Errorf("mailbox held %q, want the newest message to have replaced the oldest", msg.Payload)
+	}
+}
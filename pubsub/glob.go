@@ -0,0 +1,96 @@
+package pubsub
+
+// globMatch reports whether name matches pattern under shell-style glob
+// rules: '*' matches any run of characters, '?' matches exactly one,
+// and '[...]' matches a character class (with '^' negating it) — the
+// same subset redis supports for PSUBSCRIBE patterns.
+func globMatch(pattern, name string) bool {
+	return globMatchBytes([]byte(pattern), []byte(name))
+}
+
+func globMatchBytes(pattern, name []byte) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if globMatchBytes(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+			name, pattern = name[1:], pattern[1:]
+		case '[':
+			if len(name) == 0 {
+				return false
+			}
+			end := indexByte(pattern, ']')
+			if end < 0 {
+				if name[0] != '[' {
+					return false
+				}
+				name, pattern = name[1:], pattern[1:]
+				continue
+			}
+
+			class := pattern[1:end]
+			negate := false
+			if len(class) > 0 && class[0] == '^' {
+				negate = true
+				class = class[1:]
+			}
+			if matchClass(class, name[0]) == negate {
+				return false
+			}
+			name, pattern = name[1:], pattern[end+1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(name) == 0 || name[0] != pattern[0] {
+				return false
+			}
+			name, pattern = name[1:], pattern[1:]
+		default:
+			if len(name) == 0 || name[0] != pattern[0] {
+				return false
+			}
+			name, pattern = name[1:], pattern[1:]
+		}
+	}
+	return len(name) == 0
+}
+
+func indexByte(b []byte, c byte) int {
+	for i, x := range b {
+		if x == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func matchClass(class []byte, c byte) bool {
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			if class[i] <= c && c <= class[i+2] {
+				return true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package pubsub
+
+import "testing"
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"news.*", "news.tech", true},
+		{"news.*", "news", false},
+		{"*", "anything", true},
+		{"*", "", true},
+		{"news.??", "news.uk", true},
+		{"news.??", "news.u", false},
+		{"news.[ue]k", "news.uk", true},
+		{"news.[ue]k", "news.ek", true},
+		{"news.[ue]k", "news.fk", false},
+		{"news.[^ue]k", "news.fk", true},
+		{"news.[^ue]k", "news.uk", false},
+		{"news.[a-z]k", "news.mk", true},
+		{"news.[a-z]k", "news.5k", false},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "axb", false},
+		{"news.*.sports", "news.uk.sports", true},
+		{"news.*.sports", "news.uk.weather", false},
+	}
+
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}